@@ -15,23 +15,39 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coreos/coreos-metadata/internal/providers"
 	"github.com/coreos/coreos-metadata/internal/providers/azure"
+	"github.com/coreos/coreos-metadata/internal/providers/digitalocean"
 	"github.com/coreos/coreos-metadata/internal/providers/ec2"
 	"github.com/coreos/coreos-metadata/internal/providers/gce"
 	"github.com/coreos/coreos-metadata/internal/providers/packet"
+	"github.com/coreos/coreos-metadata/internal/providers/vmware"
 
 	"github.com/coreos/update-ssh-keys/authorized_keys_d"
 )
 
+// fetchers lists every provider coreos-metadata knows how to race, keyed by
+// the name used with -provider and on the coreos.oem.id cmdline flag.
+var fetchers = map[string]func(context.Context) (providers.Metadata, error){
+	"azure":        azure.FetchMetadata,
+	"digitalocean": digitalocean.FetchMetadata,
+	"ec2":          ec2.FetchMetadata,
+	"gce":          gce.FetchMetadata,
+	"packet":       packet.FetchMetadata,
+	"vmware":       vmware.FetchMetadata,
+}
+
 var (
 	version       = "was not built properly"
 	versionString = fmt.Sprintf("coreos-metadata %s", version)
@@ -44,17 +60,25 @@ const (
 
 func main() {
 	flags := struct {
-		cmdline    bool
-		provider   string
-		attributes string
-		sshKeys    string
-		version    bool
+		cmdline      bool
+		provider     stringsFlag
+		attributes   string
+		sshKeys      stringsFlag
+		networkUnits string
+		userData     string
+		files        string
+		timeout      time.Duration
+		version      bool
 	}{}
 
 	flag.BoolVar(&flags.cmdline, "cmdline", false, "Read the cloud provider from the kernel cmdline")
-	flag.StringVar(&flags.provider, "provider", "", "The name of the cloud provider")
+	flag.Var(&flags.provider, "provider", "The name of a cloud provider to race (may be given multiple times, or as \"auto\" to race them all)")
 	flag.StringVar(&flags.attributes, "attributes", "", "The file into which the metadata attributes are written")
-	flag.StringVar(&flags.sshKeys, "ssh-keys", "", "Update SSH keys for the given user")
+	flag.Var(&flags.sshKeys, "ssh-keys", "Update SSH keys for the given user (may be given multiple times, or as a comma-separated list)")
+	flag.StringVar(&flags.networkUnits, "network-units", "", "The directory into which network units are written")
+	flag.StringVar(&flags.userData, "user-data", "", "The file into which the provider's user-data is written")
+	flag.StringVar(&flags.files, "files", "", "The root directory under which provider-specified files are written")
+	flag.DurationVar(&flags.timeout, "timeout", 2*time.Minute, "Overall timeout for fetching metadata")
 	flag.BoolVar(&flags.version, "version", false, "Print the version and exit")
 
 	flag.Parse()
@@ -64,24 +88,20 @@ func main() {
 		return
 	}
 
-	if flags.cmdline && flags.provider == "" {
+	if flags.cmdline && len(flags.provider.values) == 0 {
 		args, err := ioutil.ReadFile(cmdlinePath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "could not read cmdline: %v\n", err)
 			os.Exit(2)
 		}
 
-		flags.provider = parseCmdline(args)
+		flags.provider.values = []string{parseCmdline(args)}
 	}
 
-	switch flags.provider {
-	case "azure", "ec2", "gce", "packet":
-	default:
-		fmt.Fprintf(os.Stderr, "invalid provider %q\n", flags.provider)
-		os.Exit(2)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), flags.timeout)
+	defer cancel()
 
-	metadata, err := fetchMetadata(flags.provider)
+	metadata, err := fetchMetadata(ctx, flags.provider.values)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to fetch metadata: %v\n", err)
 		os.Exit(1)
@@ -92,10 +112,40 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := writeMetadataKeys(flags.sshKeys, metadata); err != nil {
+	if err := writeMetadataKeys(flags.sshKeys.values, metadata); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to write metadata keys: %v\n", err)
 		os.Exit(1)
 	}
+
+	if err := writeNetworkUnits(flags.networkUnits, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write network units: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeUserData(flags.userData, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write user-data: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeMetadataFiles(flags.files, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write files: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// stringsFlag accumulates a flag.Value across repeated occurrences on the
+// command line, also splitting each occurrence on commas.
+type stringsFlag struct {
+	values []string
+}
+
+func (f *stringsFlag) String() string {
+	return strings.Join(f.values, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	f.values = append(f.values, strings.Split(value, ",")...)
+	return nil
 }
 
 func parseCmdline(cmdline []byte) (oem string) {
@@ -115,19 +165,70 @@ func parseCmdline(cmdline []byte) (oem string) {
 	return
 }
 
-func fetchMetadata(provider string) (providers.Metadata, error) {
-	switch provider {
-	case "azure":
-		return azure.FetchMetadata()
-	case "ec2":
-		return ec2.FetchMetadata()
-	case "gce":
-		return gce.FetchMetadata()
-	case "packet":
-		return packet.FetchMetadata()
-	default:
-		panic("bad provider")
+// fetchMetadata races the fetchers named by providerNames (or every known
+// fetcher, if providerNames is just "auto") and returns the first one that
+// succeeds before ctx is done, cancelling the rest.
+func fetchMetadata(ctx context.Context, providerNames []string) (providers.Metadata, error) {
+	names, err := resolveProviders(providerNames)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		metadata providers.Metadata
+		err      error
+	}
+
+	results := make(chan result, len(names))
+	for _, name := range names {
+		fetch := fetchers[name]
+		go func(name string, fetch func(context.Context) (providers.Metadata, error)) {
+			metadata, err := fetch(ctx)
+			if err != nil {
+				err = fmt.Errorf("%s: %v", name, err)
+			}
+			results <- result{metadata: metadata, err: err}
+		}(name, fetch)
+	}
+
+	var lastErr error
+	for range names {
+		res := <-results
+		if res.err == nil {
+			return res.metadata, nil
+		}
+		lastErr = res.err
+	}
+
+	return providers.Metadata{}, fmt.Errorf("no provider succeeded, last error: %v", lastErr)
+}
+
+func resolveProviders(providerNames []string) ([]string, error) {
+	if len(providerNames) == 0 {
+		return nil, fmt.Errorf("no provider specified")
 	}
+
+	for _, name := range providerNames {
+		if name != "auto" {
+			continue
+		}
+		all := make([]string, 0, len(fetchers))
+		for name := range fetchers {
+			all = append(all, name)
+		}
+		return all, nil
+	}
+
+	for _, name := range providerNames {
+		if _, ok := fetchers[name]; !ok {
+			return nil, fmt.Errorf("invalid provider %q", name)
+		}
+	}
+
+	return providerNames, nil
 }
 
 func writeVariable(out *os.File, key string, value string) (err error) {
@@ -162,11 +263,107 @@ func writeMetadataAttributes(attributes string, metadata providers.Metadata) err
 	return nil
 }
 
-func writeMetadataKeys(username string, metadata providers.Metadata) error {
-	if username == "" || metadata.SshKeys == nil {
+func writeUserData(userData string, metadata providers.Metadata) error {
+	if userData == "" || len(metadata.UserData) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(path.Dir(userData), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(userData, metadata.UserData, 0600)
+}
+
+// writeMetadataFiles materializes metadata.Files under root, refusing any
+// path that would escape it.
+func writeMetadataFiles(root string, metadata providers.Metadata) error {
+	if root == "" || len(metadata.Files) == 0 {
 		return nil
 	}
 
+	for _, file := range metadata.Files {
+		if err := writeMetadataFile(root, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeMetadataFile(root string, file providers.File) error {
+	dest := path.Join(root, file.Path)
+	prefix := path.Clean(root)
+	if prefix != "/" {
+		prefix += "/"
+	}
+	if !strings.HasPrefix(dest, prefix) || dest == prefix {
+		return fmt.Errorf("file path %q escapes root %q", file.Path, root)
+	}
+
+	mode := file.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(dest, file.Content, mode); err != nil {
+		return err
+	}
+
+	return chownMetadataFile(dest, file)
+}
+
+func chownMetadataFile(dest string, file providers.File) error {
+	if file.Owner == "" && file.Group == "" {
+		return nil
+	}
+
+	uid := -1
+	if file.Owner != "" {
+		usr, err := user.Lookup(file.Owner)
+		if err != nil {
+			return fmt.Errorf("unable to lookup user %q: %v", file.Owner, err)
+		}
+		uid, err = strconv.Atoi(usr.Uid)
+		if err != nil {
+			return err
+		}
+	}
+
+	gid := -1
+	if file.Group != "" {
+		grp, err := user.LookupGroup(file.Group)
+		if err != nil {
+			return fmt.Errorf("unable to lookup group %q: %v", file.Group, err)
+		}
+		gid, err = strconv.Atoi(grp.Gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Chown(dest, uid, gid)
+}
+
+func writeMetadataKeys(usernames []string, metadata providers.Metadata) error {
+	if len(usernames) == 0 || metadata.SshKeys == nil {
+		return nil
+	}
+
+	for _, username := range usernames {
+		if err := writeMetadataKeysForUser(username, metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeMetadataKeysForUser(username string, metadata providers.Metadata) error {
 	usr, err := user.Lookup(username)
 	if err != nil {
 		return fmt.Errorf("unable to lookup user %q: %v", username, err)
@@ -178,7 +375,10 @@ func writeMetadataKeys(username string, metadata providers.Metadata) error {
 	}
 	defer akd.Close()
 
-	ks := strings.Join(metadata.SshKeys, "\n")
+	keys := append([]string{}, metadata.SshKeys[""]...)
+	keys = append(keys, metadata.SshKeys[username]...)
+
+	ks := strings.Join(keys, "\n")
 	if err := akd.Add("coreos-metadata", []byte(ks), true, true); err != nil {
 		return err
 	}
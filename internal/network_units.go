@@ -0,0 +1,215 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+// writeNetworkUnits renders metadata's network interfaces as
+// systemd-networkd .netdev/.link/.network units under dir, named
+// deterministically so that networkd applies them in a stable order.
+// Bonds and VLANs get a .netdev unit that brings the virtual device into
+// existence; without it, the .network units referencing them (Bond=,
+// VLAN=) would never have a device to apply to.
+func writeNetworkUnits(dir string, metadata providers.Metadata) error {
+	if dir == "" || len(metadata.NetworkInterfaces) == 0 {
+		return nil
+	}
+
+	if err := validateBonds(metadata.NetworkInterfaces); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ifaces := make([]providers.NetworkInterface, len(metadata.NetworkInterfaces))
+	copy(ifaces, metadata.NetworkInterfaces)
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Name < ifaces[j].Name })
+
+	bondOf := bondMembership(ifaces)
+	vlansOf := vlanMembership(ifaces)
+
+	for i, iface := range ifaces {
+		if err := writeNetdevUnit(dir, i, iface); err != nil {
+			return err
+		}
+		if err := writeLinkUnit(dir, i, iface); err != nil {
+			return err
+		}
+		if err := writeNetworkUnit(dir, i, iface, bondOf[iface.Name], vlansOf[iface.Name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateBonds ensures every member device a bond references is itself a
+// declared interface, so a typo in provider metadata fails loudly instead of
+// producing a networkd unit that silently never matches anything.
+func validateBonds(ifaces []providers.NetworkInterface) error {
+	names := make(map[string]bool, len(ifaces))
+	for _, iface := range ifaces {
+		names[iface.Name] = true
+	}
+
+	for _, iface := range ifaces {
+		if iface.Bond == nil {
+			continue
+		}
+		for _, member := range iface.Bond.Interfaces {
+			if !names[member] {
+				return fmt.Errorf("bond %q references undeclared interface %q", iface.Name, member)
+			}
+		}
+	}
+
+	return nil
+}
+
+// bondMembership maps each bonded member device's name to the name of the
+// bond master it belongs to.
+func bondMembership(ifaces []providers.NetworkInterface) map[string]string {
+	membership := make(map[string]string)
+	for _, iface := range ifaces {
+		if iface.Bond == nil {
+			continue
+		}
+		for _, member := range iface.Bond.Interfaces {
+			membership[member] = iface.Name
+		}
+	}
+	return membership
+}
+
+// vlanMembership maps each parent link's name to the names of the VLAN
+// interfaces that attach to it, so the parent's .network unit can carry the
+// VLAN= directive that actually enslaves them.
+func vlanMembership(ifaces []providers.NetworkInterface) map[string][]string {
+	membership := make(map[string][]string)
+	for _, iface := range ifaces {
+		if iface.VlanID == 0 || iface.VlanLink == "" {
+			continue
+		}
+		membership[iface.VlanLink] = append(membership[iface.VlanLink], iface.Name)
+	}
+	return membership
+}
+
+func unitPrefix(index int) string {
+	return fmt.Sprintf("%02d", index)
+}
+
+func writeLinkUnit(dir string, index int, iface providers.NetworkInterface) error {
+	if iface.MacAddress == "" && iface.Mtu == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "[Match]")
+	if iface.MacAddress != "" {
+		fmt.Fprintf(&buf, "MACAddress=%s\n", iface.MacAddress)
+	} else {
+		fmt.Fprintf(&buf, "OriginalName=%s\n", iface.Name)
+	}
+
+	fmt.Fprintln(&buf, "\n[Link]")
+	fmt.Fprintf(&buf, "Name=%s\n", iface.Name)
+	if iface.Mtu != 0 {
+		fmt.Fprintf(&buf, "MTUBytes=%d\n", iface.Mtu)
+	}
+
+	name := fmt.Sprintf("%s-%s.link", unitPrefix(index), iface.Name)
+	return ioutil.WriteFile(path.Join(dir, name), buf.Bytes(), 0644)
+}
+
+// writeNetdevUnit renders the .netdev unit that brings a virtual device
+// (bond or VLAN) into existence. Physical interfaces have no .netdev.
+func writeNetdevUnit(dir string, index int, iface providers.NetworkInterface) error {
+	var buf bytes.Buffer
+	switch {
+	case iface.Bond != nil:
+		fmt.Fprintln(&buf, "[NetDev]")
+		fmt.Fprintf(&buf, "Name=%s\n", iface.Name)
+		fmt.Fprintln(&buf, "Kind=bond")
+		if iface.Bond.MacAddress != "" {
+			fmt.Fprintf(&buf, "MACAddress=%s\n", iface.Bond.MacAddress)
+		}
+		if iface.Bond.MinLinks != 0 {
+			fmt.Fprintln(&buf, "\n[Bond]")
+			fmt.Fprintf(&buf, "MinLinks=%d\n", iface.Bond.MinLinks)
+		}
+	case iface.VlanID != 0:
+		fmt.Fprintln(&buf, "[NetDev]")
+		fmt.Fprintf(&buf, "Name=%s\n", iface.Name)
+		fmt.Fprintln(&buf, "Kind=vlan")
+		fmt.Fprintln(&buf, "\n[VLAN]")
+		fmt.Fprintf(&buf, "Id=%d\n", iface.VlanID)
+	default:
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%s.netdev", unitPrefix(index), iface.Name)
+	return ioutil.WriteFile(path.Join(dir, name), buf.Bytes(), 0644)
+}
+
+func writeNetworkUnit(dir string, index int, iface providers.NetworkInterface, bondMaster string, vlans []string) error {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "[Match]")
+	fmt.Fprintf(&buf, "Name=%s\n", iface.Name)
+
+	var network bytes.Buffer
+	switch {
+	case bondMaster != "":
+		fmt.Fprintf(&network, "Bond=%s\n", bondMaster)
+	case iface.DHCP:
+		fmt.Fprintln(&network, "DHCP=yes")
+	}
+	for _, vlan := range vlans {
+		fmt.Fprintf(&network, "VLAN=%s\n", vlan)
+	}
+	for _, dns := range iface.DNSServers {
+		fmt.Fprintf(&network, "DNS=%s\n", dns)
+	}
+	for _, addr := range iface.Addresses {
+		fmt.Fprintf(&network, "Address=%s\n", addr.String())
+	}
+
+	if network.Len() > 0 {
+		fmt.Fprintln(&buf, "\n[Network]")
+		buf.Write(network.Bytes())
+	}
+
+	for _, route := range iface.Routes {
+		fmt.Fprintln(&buf, "\n[Route]")
+		fmt.Fprintf(&buf, "Destination=%s\n", route.Destination.String())
+		if route.Gateway != nil {
+			fmt.Fprintf(&buf, "Gateway=%s\n", route.Gateway.String())
+		}
+	}
+
+	name := fmt.Sprintf("%s-%s.network", unitPrefix(index), iface.Name)
+	return ioutil.WriteFile(path.Join(dir, name), buf.Bytes(), 0644)
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azure fetches metadata published by the Azure Linux agent
+// (waagent) on the local filesystem.
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+// waagent drops the instance's provisioning data under this path before
+// coreos-metadata runs.
+const ovfEnvPath = "/var/lib/waagent/ovf-env.xml"
+
+type ovfEnvironment struct {
+	ProvisioningSection struct {
+		LinuxProvisioningConfigurationSet struct {
+			CustomData string `xml:"CustomData"`
+		} `xml:"LinuxProvisioningConfigurationSet"`
+	} `xml:"ProvisioningSection"`
+}
+
+func FetchMetadata(ctx context.Context) (providers.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return providers.Metadata{}, err
+	}
+
+	userData, err := fetchUserData()
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	return providers.Metadata{
+		Attributes: map[string]string{},
+		UserData:   userData,
+	}, nil
+}
+
+// fetchUserData reads the base64-encoded CustomData from the copy of the
+// Azure OVF environment that waagent stored at ovfEnvPath.
+func fetchUserData() ([]byte, error) {
+	raw, err := ioutil.ReadFile(ovfEnvPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var env ovfEnvironment
+	if err := xml.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+
+	customData := env.ProvisioningSection.LinuxProvisioningConfigurationSet.CustomData
+	if customData == "" {
+		return nil, nil
+	}
+
+	return base64.StdEncoding.DecodeString(customData)
+}
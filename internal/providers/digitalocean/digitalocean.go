@@ -0,0 +1,160 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package digitalocean fetches metadata from the DigitalOcean metadata
+// service.
+package digitalocean
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/util"
+)
+
+const (
+	metadataUrl = "http://169.254.169.254/metadata/v1.json"
+)
+
+type metadataStruct struct {
+	Hostname   string   `json:"hostname"`
+	Region     string   `json:"region"`
+	DropletID  int      `json:"droplet_id"`
+	PublicKeys []string `json:"public_keys"`
+	UserData   string   `json:"user_data"`
+	Interfaces struct {
+		Public  []interfaceStruct `json:"public"`
+		Private []interfaceStruct `json:"private"`
+	} `json:"interfaces"`
+}
+
+type interfaceStruct struct {
+	MacAddress string `json:"mac"`
+	Ipv4       *struct {
+		IpAddress string `json:"ip_address"`
+		Netmask   string `json:"netmask"`
+		Gateway   string `json:"gateway"`
+	} `json:"ipv4"`
+	Ipv6 *struct {
+		IpAddress string `json:"ip_address"`
+		Cidr      int    `json:"cidr"`
+		Gateway   string `json:"gateway"`
+	} `json:"ipv6"`
+}
+
+func FetchMetadata(ctx context.Context) (providers.Metadata, error) {
+	client := util.NewHttpClient()
+
+	body, err := client.Get(ctx, metadataUrl)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	var data metadataStruct
+	if err := json.Unmarshal(body, &data); err != nil {
+		return providers.Metadata{}, err
+	}
+
+	attrs := map[string]string{
+		"HOSTNAME":   data.Hostname,
+		"REGION":     data.Region,
+		"DROPLET_ID": fmt.Sprintf("%d", data.DropletID),
+	}
+
+	for i, iface := range data.Interfaces.Public {
+		if iface.Ipv4 != nil {
+			attrs[fmt.Sprintf("IPV4_PUBLIC_%d", i)] = iface.Ipv4.IpAddress
+		}
+		if iface.Ipv6 != nil {
+			attrs[fmt.Sprintf("IPV6_PUBLIC_%d", i)] = iface.Ipv6.IpAddress
+		}
+	}
+	for i, iface := range data.Interfaces.Private {
+		if iface.Ipv4 != nil {
+			attrs[fmt.Sprintf("IPV4_PRIVATE_%d", i)] = iface.Ipv4.IpAddress
+		}
+	}
+
+	return providers.Metadata{
+		Attributes:        attrs,
+		SshKeys:           map[string][]string{"": data.PublicKeys},
+		NetworkInterfaces: networkInterfaces(data),
+		UserData:          []byte(data.UserData),
+	}, nil
+}
+
+func networkInterfaces(data metadataStruct) []providers.NetworkInterface {
+	var ifaces []providers.NetworkInterface
+
+	add := func(name string, iface interfaceStruct, public bool) {
+		ni := providers.NetworkInterface{
+			Name:       name,
+			MacAddress: iface.MacAddress,
+		}
+
+		// DigitalOcean hands out a fixed address/netmask/gateway per
+		// interface rather than running a DHCP server for it (the private
+		// VPC interface in particular has none), so configure it statically
+		// instead of emitting DHCP=yes.
+		if iface.Ipv4 != nil {
+			if ip := net.ParseIP(iface.Ipv4.IpAddress); ip != nil {
+				if mask := net.ParseIP(iface.Ipv4.Netmask); mask != nil {
+					ni.Addresses = append(ni.Addresses, net.IPNet{IP: ip, Mask: net.IPMask(mask.To4())})
+				}
+			}
+		}
+		if iface.Ipv6 != nil {
+			if ip := net.ParseIP(iface.Ipv6.IpAddress); ip != nil {
+				ni.Addresses = append(ni.Addresses, net.IPNet{IP: ip, Mask: net.CIDRMask(iface.Ipv6.Cidr, 128)})
+			}
+		}
+
+		// Only the public interface's gateway is eligible for the default
+		// route; a private interface's gateway (e.g. VPC-enabled private
+		// networking) only routes the private network, and installing a
+		// second 0.0.0.0/0 for it would compete with the public one.
+		if public {
+			if iface.Ipv4 != nil && iface.Ipv4.Gateway != "" {
+				if gw := net.ParseIP(iface.Ipv4.Gateway); gw != nil {
+					ni.Routes = append(ni.Routes, providers.NetworkRoute{
+						Destination: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+						Gateway:     gw,
+					})
+				}
+			}
+			if iface.Ipv6 != nil && iface.Ipv6.Gateway != "" {
+				if gw := net.ParseIP(iface.Ipv6.Gateway); gw != nil {
+					ni.Routes = append(ni.Routes, providers.NetworkRoute{
+						Destination: net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+						Gateway:     gw,
+					})
+				}
+			}
+		}
+
+		ifaces = append(ifaces, ni)
+	}
+
+	for i, iface := range data.Interfaces.Public {
+		add(fmt.Sprintf("eth%d", i), iface, true)
+	}
+	for i, iface := range data.Interfaces.Private {
+		add(fmt.Sprintf("eth%d", len(data.Interfaces.Public)+i), iface, false)
+	}
+
+	return ifaces
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ec2 fetches metadata from the EC2 instance metadata service.
+package ec2
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/util"
+)
+
+const (
+	metadataUrl  = "http://169.254.169.254/2009-04-04/meta-data"
+	userDataUrl  = "http://169.254.169.254/2009-04-04/user-data"
+	dynamicUrl   = "http://169.254.169.254/2009-04-04/dynamic"
+	identityPath = "instance-identity/document"
+)
+
+func FetchMetadata(ctx context.Context) (providers.Metadata, error) {
+	client := util.NewHttpClient()
+
+	attrs := map[string]string{}
+	for key, name := range map[string]string{
+		"instance-id":     "INSTANCE_ID",
+		"local-hostname":  "LOCAL_HOSTNAME",
+		"public-hostname": "PUBLIC_HOSTNAME",
+		"local-ipv4":      "IPV4_LOCAL",
+		"public-ipv4":     "IPV4_PUBLIC",
+	} {
+		value, err := client.Get(ctx, metadataUrl+"/"+key)
+		if err != nil {
+			return providers.Metadata{}, err
+		}
+		if len(value) > 0 {
+			attrs[name] = string(value)
+		}
+	}
+
+	keys, err := fetchSshKeys(ctx, client)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	ifaces, err := fetchNetworkInterfaces(ctx, client)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	userData, err := client.Get(ctx, userDataUrl)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	files, err := fetchFiles(ctx, client)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	return providers.Metadata{
+		Attributes:        attrs,
+		SshKeys:           map[string][]string{"": keys},
+		NetworkInterfaces: ifaces,
+		UserData:          userData,
+		Files:             files,
+	}, nil
+}
+
+// fetchFiles fetches the instance identity document, a JSON blob that
+// doesn't fit into the flat Attributes map, for callers that want it on
+// disk instead.
+func fetchFiles(ctx context.Context, client util.HttpClient) ([]providers.File, error) {
+	document, err := client.Get(ctx, dynamicUrl+"/"+identityPath)
+	if err != nil || len(document) == 0 {
+		return nil, err
+	}
+
+	return []providers.File{{
+		Path:    "ec2/instance-identity-document.json",
+		Content: document,
+		Mode:    0644,
+	}}, nil
+}
+
+// fetchNetworkInterfaces walks network/interfaces/macs/, which lists one
+// entry per ENI keyed by MAC address, and DHCP-configures each by its
+// device-number (the kernel's eth<N> ordering).
+func fetchNetworkInterfaces(ctx context.Context, client util.HttpClient) ([]providers.NetworkInterface, error) {
+	listing, err := client.Get(ctx, metadataUrl+"/network/interfaces/macs/")
+	if err != nil || len(listing) == 0 {
+		return nil, err
+	}
+
+	var ifaces []providers.NetworkInterface
+	for _, mac := range strings.Split(strings.TrimSpace(string(listing)), "\n") {
+		mac = strings.TrimSuffix(mac, "/")
+		if mac == "" {
+			continue
+		}
+
+		deviceNumber, err := client.Get(ctx, metadataUrl+"/network/interfaces/macs/"+mac+"/device-number")
+		if err != nil {
+			return nil, err
+		}
+
+		ifaces = append(ifaces, providers.NetworkInterface{
+			Name:       "eth" + strings.TrimSpace(string(deviceNumber)),
+			MacAddress: mac,
+			DHCP:       true,
+		})
+	}
+
+	return ifaces, nil
+}
+
+func fetchSshKeys(ctx context.Context, client util.HttpClient) ([]string, error) {
+	listing, err := client.Get(ctx, metadataUrl+"/public-keys")
+	if err != nil || len(listing) == 0 {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range strings.Split(strings.TrimSpace(string(listing)), "\n") {
+		index := strings.SplitN(entry, "=", 2)[0]
+		key, err := client.Get(ctx, metadataUrl+"/public-keys/"+index+"/openssh-key")
+		if err != nil {
+			return nil, err
+		}
+		if len(key) > 0 {
+			keys = append(keys, strings.TrimSpace(string(key)))
+		}
+	}
+
+	return keys, nil
+}
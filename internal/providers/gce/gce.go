@@ -0,0 +1,183 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gce fetches metadata from the Google Compute Engine metadata
+// server.
+package gce
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/util"
+)
+
+const (
+	metadataUrl = "http://metadata.google.internal/computeMetadata/v1/instance"
+)
+
+func newClient() util.HttpClient {
+	client := util.NewHttpClient()
+	client.Header.Add("Metadata-Flavor", "Google")
+	return client
+}
+
+func FetchMetadata(ctx context.Context) (providers.Metadata, error) {
+	client := newClient()
+
+	attrs := map[string]string{}
+	for key, name := range map[string]string{
+		"hostname":            "HOSTNAME",
+		"id":                  "INSTANCE_ID",
+		"attributes/hostname": "ATTR_HOSTNAME",
+	} {
+		value, err := client.Get(ctx, metadataUrl+"/"+key)
+		if err != nil {
+			return providers.Metadata{}, err
+		}
+		if len(value) > 0 {
+			attrs[name] = string(value)
+		}
+	}
+
+	keys, err := fetchSshKeys(ctx, client)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	ifaces, err := fetchNetworkInterfaces(ctx, client)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	userData, err := client.Get(ctx, metadataUrl+"/attributes/user-data")
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	files, err := fetchFiles(ctx, client)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	return providers.Metadata{
+		Attributes:        attrs,
+		SshKeys:           keys,
+		NetworkInterfaces: ifaces,
+		UserData:          userData,
+		Files:             files,
+	}, nil
+}
+
+// filePrefix marks instance attributes that should be materialized
+// as files rather than exported as COREOS_* attributes. The path is the
+// remainder of the key with "-" read as a directory separator, since GCE
+// attribute keys can't contain "/".
+const filePrefix = "coreos-file-"
+
+func fetchFiles(ctx context.Context, client util.HttpClient) ([]providers.File, error) {
+	listing, err := client.Get(ctx, metadataUrl+"/attributes/")
+	if err != nil || len(listing) == 0 {
+		return nil, err
+	}
+
+	var files []providers.File
+	for _, key := range strings.Split(strings.TrimSpace(string(listing)), "\n") {
+		key = strings.TrimSpace(key)
+		if !strings.HasPrefix(key, filePrefix) {
+			continue
+		}
+
+		content, err := client.Get(ctx, metadataUrl+"/attributes/"+key)
+		if err != nil {
+			return nil, err
+		}
+
+		filePath := strings.Replace(strings.TrimPrefix(key, filePrefix), "-", "/", -1)
+		files = append(files, providers.File{
+			Path:    filePath,
+			Content: content,
+			Mode:    0644,
+		})
+	}
+
+	return files, nil
+}
+
+// fetchSshKeys parses instance/attributes/ssh-keys, a newline-separated list
+// of "user:ssh-rsa ..." entries, into keys scoped to each user.
+func fetchSshKeys(ctx context.Context, client util.HttpClient) (map[string][]string, error) {
+	listing, err := client.Get(ctx, metadataUrl+"/attributes/ssh-keys")
+	if err != nil || len(listing) == 0 {
+		return nil, err
+	}
+
+	keys := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(listing)), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		user := strings.TrimSpace(parts[0])
+		keys[user] = append(keys[user], strings.TrimSpace(parts[1]))
+	}
+
+	return keys, nil
+}
+
+// fetchNetworkInterfaces walks instance/network-interfaces/, DHCP-configuring
+// each NIC and carrying over any custom DNS servers GCE assigned it.
+func fetchNetworkInterfaces(ctx context.Context, client util.HttpClient) ([]providers.NetworkInterface, error) {
+	listing, err := client.Get(ctx, metadataUrl+"/network-interfaces/")
+	if err != nil || len(listing) == 0 {
+		return nil, err
+	}
+
+	var ifaces []providers.NetworkInterface
+	for _, index := range strings.Split(strings.TrimSpace(string(listing)), "\n") {
+		index = strings.TrimSuffix(index, "/")
+		if index == "" {
+			continue
+		}
+		base := metadataUrl + "/network-interfaces/" + index
+
+		mac, err := client.Get(ctx, base+"/mac")
+		if err != nil {
+			return nil, err
+		}
+
+		dnsList, err := client.Get(ctx, base+"/dns-servers")
+		if err != nil {
+			return nil, err
+		}
+
+		var dns []net.IP
+		for _, line := range strings.Split(strings.TrimSpace(string(dnsList)), "\n") {
+			if ip := net.ParseIP(strings.TrimSpace(line)); ip != nil {
+				dns = append(dns, ip)
+			}
+		}
+
+		ifaces = append(ifaces, providers.NetworkInterface{
+			Name:       "eth" + index,
+			MacAddress: strings.TrimSpace(string(mac)),
+			DHCP:       true,
+			DNSServers: dns,
+		})
+	}
+
+	return ifaces, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import "net"
+
+// NetworkBond describes a bonded interface aggregating one or more member
+// devices.
+type NetworkBond struct {
+	Name       string
+	Interfaces []string
+	MacAddress string
+	MinLinks   int
+}
+
+// NetworkRoute is a single static route to be installed on an interface.
+type NetworkRoute struct {
+	Destination net.IPNet
+	Gateway     net.IP
+}
+
+// NetworkInterface describes the network configuration for a single
+// physical or virtual interface, as exposed by provider metadata. A zero
+// value VlanID means the interface is not a VLAN; when VlanID is set,
+// VlanLink names the parent interface the VLAN rides on.
+type NetworkInterface struct {
+	Name       string
+	MacAddress string
+	Mtu        int
+	DHCP       bool
+	Bond       *NetworkBond
+	VlanID     int
+	VlanLink   string
+	Addresses  []net.IPNet
+	Routes     []NetworkRoute
+	DNSServers []net.IP
+}
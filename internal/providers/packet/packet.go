@@ -0,0 +1,186 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package packet fetches metadata from the Packet metadata service.
+package packet
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/util"
+)
+
+const (
+	metadataUrl = "https://metadata.packet.net/metadata"
+	bondName    = "bond0"
+)
+
+type metadataStruct struct {
+	Hostname string `json:"hostname"`
+	Plan     string `json:"plan"`
+	Facility string `json:"facility"`
+	// CustomData is an arbitrary user-supplied blob, not a flat string, so it
+	// can't be exported as a COREOS_* attribute like the rest of this struct.
+	CustomData json.RawMessage `json:"customdata"`
+	SshKeys    []struct {
+		Key string `json:"key"`
+		// User is set for project keys scoped to a single account user,
+		// and empty for keys that apply to every requested user.
+		User string `json:"user"`
+	} `json:"ssh_keys"`
+	Network struct {
+		Interfaces []struct {
+			Name string `json:"name"`
+			Mac  string `json:"mac"`
+		} `json:"interfaces"`
+		Addresses []struct {
+			Address string `json:"address"`
+			Cidr    int    `json:"cidr"`
+			Family  int    `json:"address_family"`
+			Gateway string `json:"gateway"`
+			Public  bool   `json:"public"`
+		} `json:"addresses"`
+	} `json:"network"`
+}
+
+func FetchMetadata(ctx context.Context) (providers.Metadata, error) {
+	client := util.NewHttpClient()
+
+	body, err := client.Get(ctx, metadataUrl)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	var data metadataStruct
+	if err := json.Unmarshal(body, &data); err != nil {
+		return providers.Metadata{}, err
+	}
+
+	return providers.Metadata{
+		Attributes: map[string]string{
+			"HOSTNAME": data.Hostname,
+			"PLAN":     data.Plan,
+			"FACILITY": data.Facility,
+		},
+		SshKeys:           sshKeys(data),
+		NetworkInterfaces: networkInterfaces(data),
+		Files:             files(data),
+	}, nil
+}
+
+// files maps Packet metadata fields that aren't simple strings onto
+// -files output, rather than trying to flatten them into Attributes.
+func files(data metadataStruct) []providers.File {
+	if len(data.CustomData) == 0 {
+		return nil
+	}
+
+	return []providers.File{{
+		Path:    "packet/customdata.json",
+		Content: []byte(data.CustomData),
+		Mode:    0644,
+	}}
+}
+
+func sshKeys(data metadataStruct) map[string][]string {
+	keys := map[string][]string{}
+	for _, key := range data.SshKeys {
+		keys[key.User] = append(keys[key.User], key.Key)
+	}
+	return keys
+}
+
+// networkInterfaces translates Packet's bonded-NIC network metadata into a
+// single bond0 master carrying every address/route, with the physical NICs
+// declared as its members.
+func networkInterfaces(data metadataStruct) []providers.NetworkInterface {
+	if len(data.Network.Interfaces) == 0 {
+		return nil
+	}
+
+	bond := providers.NetworkBond{
+		Name: bondName,
+	}
+
+	ifaces := make([]providers.NetworkInterface, 0, len(data.Network.Interfaces)+1)
+	for _, nic := range data.Network.Interfaces {
+		bond.Interfaces = append(bond.Interfaces, nic.Name)
+		ifaces = append(ifaces, providers.NetworkInterface{
+			Name:       nic.Name,
+			MacAddress: nic.Mac,
+		})
+	}
+
+	var addrs []net.IPNet
+	var routes []providers.NetworkRoute
+	for _, addr := range data.Network.Addresses {
+		ip := net.ParseIP(addr.Address)
+		if ip == nil {
+			continue
+		}
+
+		bits := 32
+		if addr.Family == 6 {
+			bits = 128
+		}
+		addrs = append(addrs, net.IPNet{IP: ip, Mask: net.CIDRMask(addr.Cidr, bits)})
+
+		if gw := net.ParseIP(addr.Gateway); gw != nil {
+			if addr.Public {
+				dest := net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}
+				if addr.Family == 6 {
+					dest = net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+				}
+				routes = append(routes, providers.NetworkRoute{Destination: dest, Gateway: gw})
+			} else {
+				// The private gateway only routes the RFC1918 (or IPv6
+				// unique-local) space, not the default route, so install
+				// routes for exactly that space rather than 0.0.0.0/0.
+				for _, dest := range privateRoutes(addr.Family) {
+					routes = append(routes, providers.NetworkRoute{Destination: dest, Gateway: gw})
+				}
+			}
+		}
+	}
+
+	ifaces = append(ifaces, providers.NetworkInterface{
+		Name:      bondName,
+		Bond:      &bond,
+		Addresses: addrs,
+		Routes:    routes,
+	})
+
+	return ifaces
+}
+
+// privateRoutes returns the supernets a Packet private gateway actually
+// routes for the given address family: RFC1918 space for IPv4, or the
+// unique-local block for IPv6.
+func privateRoutes(family int) []net.IPNet {
+	blocks := []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+	if family == 6 {
+		blocks = []string{"fc00::/7"}
+	}
+
+	dests := make([]net.IPNet, 0, len(blocks))
+	for _, block := range blocks {
+		if _, dest, err := net.ParseCIDR(block); err == nil {
+			dests = append(dests, *dest)
+		}
+	}
+	return dests
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package providers defines the data providers fetch from cloud metadata
+// services and hand back to main for writing out to disk.
+package providers
+
+import "os"
+
+// Metadata is the sum of everything a provider can discover about the
+// instance it is running on.
+type Metadata struct {
+	Attributes map[string]string
+	// SshKeys maps a username to the keys a provider scoped to that user.
+	// The "" key holds keys that apply to every user named with -ssh-keys,
+	// for providers that don't expose per-user assignment.
+	SshKeys           map[string][]string
+	NetworkInterfaces []NetworkInterface
+	UserData          []byte
+	Files             []File
+}
+
+// File describes a single file a provider wants written out verbatim,
+// relative to the root directory passed to -files.
+type File struct {
+	Path    string
+	Content []byte
+	Mode    os.FileMode
+	Owner   string
+	Group   string
+}
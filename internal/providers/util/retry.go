@@ -0,0 +1,95 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package util holds helpers shared by the provider packages.
+package util
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+	maxAttempts    = 10
+)
+
+// HttpClient is a thin wrapper around http.Client that retries transient
+// failures with capped exponential backoff, so providers don't each have to
+// reimplement retry logic against their (often flaky, just-booted) metadata
+// endpoints.
+type HttpClient struct {
+	Client http.Client
+	Header http.Header
+}
+
+func NewHttpClient() HttpClient {
+	return HttpClient{Header: make(http.Header)}
+}
+
+// Get fetches url, retrying on network errors and non-200 responses for at
+// most maxAttempts attempts, or until ctx is done. A 404 is not retried: it
+// returns a nil body and a nil error.
+func (c HttpClient) Get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.Header
+
+	backoff := initialBackoff
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+			continue
+		}
+
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("failed to fetch %s: %v", url, lastErr)
+}
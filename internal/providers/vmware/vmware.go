@@ -0,0 +1,113 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vmware fetches metadata set by the hypervisor through VMware's
+// guestinfo interface, as exposed by open-vm-tools/vmtoolsd.
+package vmware
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+// backend reads a single guestinfo key and returns its raw value. It is a
+// variable so tests can swap in a fake without shelling out to vmtoolsd.
+var backend = rpctoolBackend
+
+type guestMetadata struct {
+	Hostname string          `yaml:"hostname"`
+	SshKeys  []string        `yaml:"ssh-keys"`
+	Network  networkMetadata `yaml:"network"`
+}
+
+type networkMetadata struct {
+	Interfaces []interfaceMetadata `yaml:"interfaces"`
+}
+
+type interfaceMetadata struct {
+	Name       string   `yaml:"name"`
+	MacAddress string   `yaml:"mac"`
+	DHCP       bool     `yaml:"dhcp"`
+	Addresses  []string `yaml:"addresses"`
+}
+
+func FetchMetadata(ctx context.Context) (providers.Metadata, error) {
+	raw, err := backend(ctx, "guestinfo.metadata")
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+	if raw == "" {
+		return providers.Metadata{}, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	var data guestMetadata
+	if err := yaml.Unmarshal(decoded, &data); err != nil {
+		return providers.Metadata{}, err
+	}
+
+	attrs := map[string]string{}
+	if data.Hostname != "" {
+		attrs["HOSTNAME"] = data.Hostname
+	}
+
+	var ifaces []providers.NetworkInterface
+	for _, iface := range data.Network.Interfaces {
+		ni := providers.NetworkInterface{
+			Name:       iface.Name,
+			MacAddress: iface.MacAddress,
+			DHCP:       iface.DHCP,
+		}
+
+		for _, addr := range iface.Addresses {
+			if ip, ipNet, err := net.ParseCIDR(addr); err == nil {
+				ni.Addresses = append(ni.Addresses, net.IPNet{IP: ip, Mask: ipNet.Mask})
+			}
+		}
+
+		ifaces = append(ifaces, ni)
+	}
+
+	return providers.Metadata{
+		Attributes:        attrs,
+		SshKeys:           map[string][]string{"": data.SshKeys},
+		NetworkInterfaces: ifaces,
+	}, nil
+}
+
+// rpctoolBackend shells out to vmware-rpctool, which talks to vmtoolsd over
+// the VMware backdoor, to read a single guestinfo key.
+func rpctoolBackend(ctx context.Context, key string) (string, error) {
+	out, err := exec.CommandContext(ctx, "vmware-rpctool", "info-get "+key).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) == 0 {
+			// vmware-rpctool exits non-zero when the key isn't set.
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}